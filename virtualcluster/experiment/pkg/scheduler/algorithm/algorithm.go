@@ -0,0 +1,127 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package algorithm
+
+import (
+	"fmt"
+
+	"sigs.k8s.io/cluster-api-provider-nested/virtualcluster/experiment/pkg/scheduler/algorithm/framework"
+	internalcache "sigs.k8s.io/cluster-api-provider-nested/virtualcluster/experiment/pkg/scheduler/cache"
+)
+
+// SliceInfo describes a single slice of a namespace's quota that needs to be
+// placed onto a super cluster.
+type SliceInfo struct {
+	NamespaceKey string
+	Size         int
+
+	// MandatoryCluster, if set, is a super cluster the slice must be placed
+	// onto (e.g. because it is already placed there and cannot move).
+	MandatoryCluster string
+	// HintCluster, if set, is the super cluster the slice was previously
+	// placed onto and should be preferred to minimize churn.
+	HintCluster string
+	// Policy is the placement policy to honor for this slice; it does not
+	// restrict placement onto MandatoryCluster, but does apply to hinted
+	// slices. One of internalcache.PlacementPolicyPack or
+	// internalcache.PlacementPolicySpread.
+	Policy string
+
+	// Result is the super cluster the algorithm placed this slice onto.
+	Result string
+	// Err is set if this slice could not be placed.
+	Err error
+}
+
+// SliceInfoArray is a list of slices to be scheduled together.
+type SliceInfoArray []*SliceInfo
+
+// Repeat appends n copies of a slice with the given sizing, hints and
+// placement policy.
+func (s *SliceInfoArray) Repeat(n int, namespaceKey string, size int, mandatoryCluster, hintCluster, policy string) {
+	for i := 0; i < n; i++ {
+		*s = append(*s, &SliceInfo{
+			NamespaceKey:     namespaceKey,
+			Size:             size,
+			MandatoryCluster: mandatoryCluster,
+			HintCluster:      hintCluster,
+			Policy:           policy,
+		})
+	}
+}
+
+// ScheduleNamespaceSlices places every slice in slices onto a super cluster
+// from snapshot by running each through fw's Filter -> Score ->
+// NormalizeScore -> Reserve plugin pipeline, sharing one CycleState across
+// the whole batch so plugins like framework.SpreadCap can track cumulative
+// placements. It mutates and returns slices with Result/Err populated,
+// clearing any Result/Err left over from a prior call so callers may retry a
+// failed SliceInfoArray (e.g. after freeing capacity via preemption) by
+// passing the same slices back in.
+func ScheduleNamespaceSlices(slices SliceInfoArray, snapshot *internalcache.NamespaceSchedSnapshot, fw *framework.Framework) SliceInfoArray {
+	free := make(map[string]int, len(snapshot.Clusters))
+	total := make(map[string]int, len(snapshot.Clusters))
+	for _, c := range snapshot.Clusters {
+		free[c.Cluster] = c.FreeSlices
+		total[c.Cluster] = c.TotalSlices
+	}
+
+	state := framework.NewCycleState()
+	if numClusters := len(free); numClusters > 0 {
+		state.Write(framework.SpreadCapStateKey, (len(slices)+numClusters-1)/numClusters)
+	}
+
+	for _, slice := range slices {
+		slice.Result = ""
+		slice.Err = nil
+		candidates := make([]*framework.Candidate, 0, len(free))
+		for cluster, n := range free {
+			candidates = append(candidates, &framework.Candidate{Cluster: cluster, FreeSlices: n, TotalSlices: total[cluster]})
+		}
+		req := &framework.SliceRequest{
+			NamespaceKey:     slice.NamespaceKey,
+			Size:             slice.Size,
+			MandatoryCluster: slice.MandatoryCluster,
+			HintCluster:      slice.HintCluster,
+			Policy:           slice.Policy,
+		}
+
+		cluster, err := fw.SelectCluster(state, req, candidates)
+		if err != nil {
+			slice.Err = fmt.Errorf("no super cluster has free capacity to place slice of namespace %s: %w", slice.NamespaceKey, err)
+			continue
+		}
+		free[cluster]--
+		slice.Result = cluster
+	}
+	return slices
+}
+
+// SchedulePod picks the super cluster a pod should run on, which must be one
+// of the clusters its namespace already has slices placed onto, by running
+// the namespace's placed clusters through fw's plugin pipeline.
+func SchedulePod(pod *internalcache.Pod, snapshot *internalcache.PodSchedSnapshot, fw *framework.Framework) (string, error) {
+	if len(snapshot.Clusters) == 0 {
+		return "", fmt.Errorf("namespace %s has no placements to schedule pod %s onto", pod.GetNamespaceKey(), pod.GetKey())
+	}
+	candidates := make([]*framework.Candidate, 0, len(snapshot.Clusters))
+	for _, cluster := range snapshot.Clusters {
+		candidates = append(candidates, &framework.Candidate{Cluster: cluster, FreeSlices: 1, TotalSlices: 1})
+	}
+	req := &framework.SliceRequest{NamespaceKey: pod.GetNamespaceKey()}
+	return fw.SelectCluster(framework.NewCycleState(), req, candidates)
+}
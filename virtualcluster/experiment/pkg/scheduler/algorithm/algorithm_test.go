@@ -0,0 +1,93 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package algorithm
+
+import (
+	"testing"
+
+	"sigs.k8s.io/cluster-api-provider-nested/virtualcluster/experiment/pkg/scheduler/algorithm/framework"
+	internalcache "sigs.k8s.io/cluster-api-provider-nested/virtualcluster/experiment/pkg/scheduler/cache"
+)
+
+func mustFramework(t *testing.T) *framework.Framework {
+	t.Helper()
+	fw, err := framework.NewDefaultFramework()
+	if err != nil {
+		t.Fatalf("NewDefaultFramework: %v", err)
+	}
+	return fw
+}
+
+// TestScheduleNamespaceSlicesClearsStaleErrOnRetry guards against a regression
+// where a slice that failed to place on a first pass kept its stale Err (and
+// empty Result) when the very same SliceInfoArray was rescheduled against a
+// snapshot with more free capacity, e.g. after preemption frees a cluster.
+func TestScheduleNamespaceSlicesClearsStaleErrOnRetry(t *testing.T) {
+	fw := mustFramework(t)
+
+	slices := make(SliceInfoArray, 0)
+	slices.Repeat(1, "ns", 1, "", "", internalcache.PlacementPolicyPack)
+
+	full := &internalcache.NamespaceSchedSnapshot{
+		Clusters: []internalcache.SuperClusterCapacity{{Cluster: "c1", FreeSlices: 0, TotalSlices: 10}},
+	}
+	slices = ScheduleNamespaceSlices(slices, full, fw)
+	if slices[0].Err == nil {
+		t.Fatalf("expected first attempt against a full cluster to fail")
+	}
+
+	freed := &internalcache.NamespaceSchedSnapshot{
+		Clusters: []internalcache.SuperClusterCapacity{{Cluster: "c1", FreeSlices: 1, TotalSlices: 10}},
+	}
+	slices = ScheduleNamespaceSlices(slices, freed, fw)
+	if slices[0].Err != nil {
+		t.Fatalf("expected retry against freed capacity to succeed, got stale Err: %v", slices[0].Err)
+	}
+	if slices[0].Result != "c1" {
+		t.Fatalf("expected slice to be placed on c1, got %q", slices[0].Result)
+	}
+}
+
+// TestScheduleNamespaceSlicesSpreadEvenlyCapsPerCluster verifies that
+// PlacementPolicySpread distributes a namespace's slices across every
+// candidate cluster up to ceil(TotalSlices/numClusters) each, instead of
+// bin-packing them onto the most-free cluster like PlacementPolicyPack does.
+func TestScheduleNamespaceSlicesSpreadEvenlyCapsPerCluster(t *testing.T) {
+	fw := mustFramework(t)
+
+	slices := make(SliceInfoArray, 0)
+	slices.Repeat(4, "ns", 1, "", "", internalcache.PlacementPolicySpread)
+
+	snapshot := &internalcache.NamespaceSchedSnapshot{
+		Clusters: []internalcache.SuperClusterCapacity{
+			{Cluster: "c1", FreeSlices: 10, TotalSlices: 10},
+			{Cluster: "c2", FreeSlices: 10, TotalSlices: 10},
+		},
+	}
+	slices = ScheduleNamespaceSlices(slices, snapshot, fw)
+
+	counts := make(map[string]int)
+	for _, slice := range slices {
+		if slice.Err != nil {
+			t.Fatalf("unexpected placement failure: %v", slice.Err)
+		}
+		counts[slice.Result]++
+	}
+	if counts["c1"] != 2 || counts["c2"] != 2 {
+		t.Fatalf("expected 4 slices spread evenly 2/2 across c1 and c2, got %v", counts)
+	}
+}
@@ -0,0 +1,217 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package framework provides a kube-scheduler-style plugin pipeline for
+// placing a single namespace slice or pod onto a super cluster: candidates
+// pass through Filter, Score and NormalizeScore extension points and the
+// winner is announced to Reserve plugins, all sharing a CycleState for the
+// duration of one scheduling pass.
+package framework
+
+import (
+	"fmt"
+	"sync"
+)
+
+// CycleState carries data between plugins across one call to
+// Framework.SelectCluster, or across an entire batch of such calls when the
+// caller reuses the same CycleState (e.g. to track cumulative placements
+// for a spread policy).
+type CycleState struct {
+	mu   sync.Mutex
+	data map[string]interface{}
+}
+
+// NewCycleState returns an empty CycleState.
+func NewCycleState() *CycleState {
+	return &CycleState{data: make(map[string]interface{})}
+}
+
+func (s *CycleState) Read(key string) (interface{}, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	v, ok := s.data[key]
+	return v, ok
+}
+
+func (s *CycleState) Write(key string, val interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data[key] = val
+}
+
+// Candidate is a super cluster being considered for a placement decision.
+type Candidate struct {
+	Cluster     string
+	FreeSlices  int
+	TotalSlices int
+}
+
+// SliceRequest is the framework's view of a single namespace slice that
+// needs to be placed onto a super cluster.
+type SliceRequest struct {
+	NamespaceKey     string
+	Size             int
+	MandatoryCluster string
+	HintCluster      string
+	Policy           string
+}
+
+// Plugin is implemented by every framework plugin. A plugin additionally
+// implements FilterPlugin, ScorePlugin, NormalizeScorePlugin and/or
+// ReservePlugin to participate in those extension points.
+type Plugin interface {
+	Name() string
+}
+
+// FilterPlugin decides whether a candidate cluster is eligible for req.
+type FilterPlugin interface {
+	Plugin
+	Filter(state *CycleState, req *SliceRequest, candidate *Candidate) bool
+}
+
+// ScorePlugin scores an eligible candidate cluster for req; higher wins.
+type ScorePlugin interface {
+	Plugin
+	Score(state *CycleState, req *SliceRequest, candidate *Candidate) (int64, error)
+}
+
+// NormalizeScorePlugin adjusts the combined, weighted scores of every
+// feasible candidate after all ScorePlugins have run.
+type NormalizeScorePlugin interface {
+	Plugin
+	NormalizeScore(state *CycleState, req *SliceRequest, scores map[string]int64) error
+}
+
+// ReservePlugin is notified once a cluster has been chosen for req so it can
+// account for the reservation in state, and roll it back on Unreserve.
+type ReservePlugin interface {
+	Plugin
+	Reserve(state *CycleState, req *SliceRequest, cluster string) error
+	Unreserve(state *CycleState, req *SliceRequest, cluster string)
+}
+
+// PluginConfig enables/disables a named plugin and, for score plugins, sets
+// its weight in the weighted sum of scores.
+type PluginConfig struct {
+	Name    string `json:"name"`
+	Enabled bool   `json:"enabled"`
+	Weight  int64  `json:"weight,omitempty"`
+}
+
+type weightedScorer struct {
+	plugin ScorePlugin
+	weight int64
+}
+
+// Framework runs a configured set of plugins through the Filter -> Score ->
+// NormalizeScore -> Reserve pipeline to place one SliceRequest at a time.
+type Framework struct {
+	filters   []FilterPlugin
+	scorers   []weightedScorer
+	normalize []NormalizeScorePlugin
+	reserves  []ReservePlugin
+}
+
+// NewFramework builds a Framework out of registry's plugins, enabling and
+// weighting them per configs. Plugins absent from configs, or present but
+// disabled, take no part in scheduling, matching kube-scheduler's explicit
+// opt-in model.
+func NewFramework(configs []PluginConfig, registry map[string]Plugin) (*Framework, error) {
+	fw := &Framework{}
+	for _, cfg := range configs {
+		if !cfg.Enabled {
+			continue
+		}
+		plugin, ok := registry[cfg.Name]
+		if !ok {
+			return nil, fmt.Errorf("unknown scheduler plugin %q", cfg.Name)
+		}
+		if f, ok := plugin.(FilterPlugin); ok {
+			fw.filters = append(fw.filters, f)
+		}
+		if s, ok := plugin.(ScorePlugin); ok {
+			weight := cfg.Weight
+			if weight == 0 {
+				weight = 1
+			}
+			fw.scorers = append(fw.scorers, weightedScorer{plugin: s, weight: weight})
+		}
+		if n, ok := plugin.(NormalizeScorePlugin); ok {
+			fw.normalize = append(fw.normalize, n)
+		}
+		if r, ok := plugin.(ReservePlugin); ok {
+			fw.reserves = append(fw.reserves, r)
+		}
+	}
+	return fw, nil
+}
+
+// SelectCluster runs req through Filter, Score, NormalizeScore and Reserve
+// against candidates, sharing state across the call, and returns the
+// winning cluster.
+func (fw *Framework) SelectCluster(state *CycleState, req *SliceRequest, candidates []*Candidate) (string, error) {
+	feasible := make([]*Candidate, 0, len(candidates))
+	for _, c := range candidates {
+		ok := true
+		for _, f := range fw.filters {
+			if !f.Filter(state, req, c) {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			feasible = append(feasible, c)
+		}
+	}
+	if len(feasible) == 0 {
+		return "", fmt.Errorf("no super cluster passed filtering for namespace %s", req.NamespaceKey)
+	}
+
+	scores := make(map[string]int64, len(feasible))
+	for _, scorer := range fw.scorers {
+		for _, c := range feasible {
+			score, err := scorer.plugin.Score(state, req, c)
+			if err != nil {
+				return "", fmt.Errorf("plugin %s: %w", scorer.plugin.Name(), err)
+			}
+			scores[c.Cluster] += score * scorer.weight
+		}
+	}
+	for _, n := range fw.normalize {
+		if err := n.NormalizeScore(state, req, scores); err != nil {
+			return "", fmt.Errorf("plugin %s: %w", n.Name(), err)
+		}
+	}
+
+	best := ""
+	var bestScore int64
+	for _, c := range feasible {
+		if best == "" || scores[c.Cluster] > bestScore {
+			best, bestScore = c.Cluster, scores[c.Cluster]
+		}
+	}
+
+	for i, r := range fw.reserves {
+		if err := r.Reserve(state, req, best); err != nil {
+			for _, reserved := range fw.reserves[:i] {
+				reserved.Unreserve(state, req, best)
+			}
+			return "", fmt.Errorf("plugin %s: %w", r.Name(), err)
+		}
+	}
+	return best, nil
+}
@@ -0,0 +1,157 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package framework
+
+import (
+	"fmt"
+	"testing"
+
+	internalcache "sigs.k8s.io/cluster-api-provider-nested/virtualcluster/experiment/pkg/scheduler/cache"
+)
+
+func TestSelectClusterPrefersMandatoryCluster(t *testing.T) {
+	fw, err := NewDefaultFramework()
+	if err != nil {
+		t.Fatalf("NewDefaultFramework: %v", err)
+	}
+
+	req := &SliceRequest{MandatoryCluster: "c1"}
+	candidates := []*Candidate{
+		{Cluster: "c1", FreeSlices: 0, TotalSlices: 10},
+		{Cluster: "c2", FreeSlices: 10, TotalSlices: 10},
+	}
+	cluster, err := fw.SelectCluster(NewCycleState(), req, candidates)
+	if err != nil {
+		t.Fatalf("SelectCluster: %v", err)
+	}
+	if cluster != "c1" {
+		t.Fatalf("expected the mandatory cluster to win even with no free capacity, got %q", cluster)
+	}
+}
+
+func TestSelectClusterFiltersOutFullClusters(t *testing.T) {
+	fw, err := NewDefaultFramework()
+	if err != nil {
+		t.Fatalf("NewDefaultFramework: %v", err)
+	}
+
+	req := &SliceRequest{}
+	candidates := []*Candidate{
+		{Cluster: "c1", FreeSlices: 0, TotalSlices: 10},
+		{Cluster: "c2", FreeSlices: 1, TotalSlices: 10},
+	}
+	cluster, err := fw.SelectCluster(NewCycleState(), req, candidates)
+	if err != nil {
+		t.Fatalf("SelectCluster: %v", err)
+	}
+	if cluster != "c2" {
+		t.Fatalf("expected the only cluster with free capacity to win, got %q", cluster)
+	}
+}
+
+func TestSelectClusterNoFeasibleCandidates(t *testing.T) {
+	fw, err := NewDefaultFramework()
+	if err != nil {
+		t.Fatalf("NewDefaultFramework: %v", err)
+	}
+
+	req := &SliceRequest{}
+	candidates := []*Candidate{{Cluster: "c1", FreeSlices: 0, TotalSlices: 10}}
+	if _, err := fw.SelectCluster(NewCycleState(), req, candidates); err == nil {
+		t.Fatalf("expected an error when no candidate has free capacity")
+	}
+}
+
+// countingReserve is a ReservePlugin stub that records Reserve/Unreserve
+// calls and, if failOn is non-empty, fails Reserve for that cluster.
+type countingReserve struct {
+	name       string
+	failOn     string
+	reserved   []string
+	unreserved []string
+}
+
+func (p *countingReserve) Name() string { return p.name }
+
+func (p *countingReserve) Reserve(state *CycleState, req *SliceRequest, cluster string) error {
+	if cluster == p.failOn {
+		return fmt.Errorf("%s: refusing to reserve %s", p.name, cluster)
+	}
+	p.reserved = append(p.reserved, cluster)
+	return nil
+}
+
+func (p *countingReserve) Unreserve(state *CycleState, req *SliceRequest, cluster string) {
+	p.unreserved = append(p.unreserved, cluster)
+}
+
+// TestSelectClusterUnreservesOnLaterReserveFailure guards against a
+// regression where a later Reserve plugin's failure was surfaced as an error
+// without rolling back earlier plugins that had already reserved the winning
+// cluster, leaving their bookkeeping permanently out of sync with the actual
+// (failed) placement.
+func TestSelectClusterUnreservesOnLaterReserveFailure(t *testing.T) {
+	first := &countingReserve{name: "first"}
+	second := &countingReserve{name: "second", failOn: "c1"}
+	fw := &Framework{reserves: []ReservePlugin{first, second}}
+
+	req := &SliceRequest{}
+	candidates := []*Candidate{{Cluster: "c1", FreeSlices: 10, TotalSlices: 10}}
+	if _, err := fw.SelectCluster(NewCycleState(), req, candidates); err == nil {
+		t.Fatalf("expected an error when the second reserve plugin fails")
+	}
+
+	if len(first.reserved) != 1 || first.reserved[0] != "c1" {
+		t.Fatalf("expected first to have reserved c1 before second failed, got %v", first.reserved)
+	}
+	if len(first.unreserved) != 1 || first.unreserved[0] != "c1" {
+		t.Fatalf("expected first to be unreserved after second's Reserve failed, got %v", first.unreserved)
+	}
+	if len(second.unreserved) != 0 {
+		t.Fatalf("expected the failing plugin itself not to be unreserved, got %v", second.unreserved)
+	}
+}
+
+func TestSpreadCapEnforcesPerClusterCeiling(t *testing.T) {
+	fw, err := NewFramework(DefaultConfig(), DefaultRegistry())
+	if err != nil {
+		t.Fatalf("NewFramework: %v", err)
+	}
+
+	state := NewCycleState()
+	state.Write(SpreadCapStateKey, 1)
+	req := &SliceRequest{Policy: internalcache.PlacementPolicySpread}
+	candidates := []*Candidate{
+		{Cluster: "c1", FreeSlices: 10, TotalSlices: 10},
+		{Cluster: "c2", FreeSlices: 10, TotalSlices: 10},
+	}
+
+	first, err := fw.SelectCluster(state, req, candidates)
+	if err != nil {
+		t.Fatalf("SelectCluster (first): %v", err)
+	}
+
+	// With the cap already reached on first, only the other cluster should
+	// remain feasible for a second slice of the same batch.
+	second, err := fw.SelectCluster(state, req, candidates)
+	if err != nil {
+		t.Fatalf("SelectCluster (second): %v", err)
+	}
+	if first == second {
+		t.Fatalf("expected SpreadCap to steer the second slice to a different cluster, got %q twice", first)
+	}
+}
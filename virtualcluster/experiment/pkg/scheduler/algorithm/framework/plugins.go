@@ -0,0 +1,183 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package framework
+
+import (
+	internalcache "sigs.k8s.io/cluster-api-provider-nested/virtualcluster/experiment/pkg/scheduler/cache"
+)
+
+// SpreadCapStateKey is the CycleState key ScheduleNamespaceSlices seeds with
+// the ideal per-cluster slice cap, ceil(TotalSlices/numClusters), before
+// scheduling a namespace's slices under PlacementPolicySpread. SpreadCap
+// reads it; callers not using that policy may leave it unset.
+const SpreadCapStateKey = "framework/spread-cap"
+
+const spreadPlacedStateKey = "framework/spread-placed"
+
+// MandatoryPlacement restricts candidates to req.MandatoryCluster, and
+// scores it highest, mirroring the engine's original mandatory-placement
+// behavior for slices that are already bound to a cluster.
+type MandatoryPlacement struct{}
+
+func NewMandatoryPlacement() *MandatoryPlacement { return &MandatoryPlacement{} }
+
+func (p *MandatoryPlacement) Name() string { return "MandatoryPlacement" }
+
+func (p *MandatoryPlacement) Filter(_ *CycleState, req *SliceRequest, candidate *Candidate) bool {
+	return req.MandatoryCluster == "" || candidate.Cluster == req.MandatoryCluster
+}
+
+func (p *MandatoryPlacement) Score(_ *CycleState, req *SliceRequest, candidate *Candidate) (int64, error) {
+	if req.MandatoryCluster != "" && candidate.Cluster == req.MandatoryCluster {
+		return 100, nil
+	}
+	return 0, nil
+}
+
+// OldPlacementHint scores req.HintCluster highly to minimize churn from a
+// namespace's previous placement, without making it mandatory: a hint
+// cluster with no free capacity is filtered out by FreeCapacity, and other
+// score plugins can outweigh the hint's score.
+type OldPlacementHint struct{}
+
+func NewOldPlacementHint() *OldPlacementHint { return &OldPlacementHint{} }
+
+func (p *OldPlacementHint) Name() string { return "OldPlacementHint" }
+
+func (p *OldPlacementHint) Score(_ *CycleState, req *SliceRequest, candidate *Candidate) (int64, error) {
+	if req.HintCluster != "" && candidate.Cluster == req.HintCluster {
+		return 100, nil
+	}
+	return 0, nil
+}
+
+// FreeCapacity filters out clusters with no free slices and scores the rest
+// proportionally to how much free capacity they have, which bin-packs
+// namespaces under PlacementPolicyPack.
+type FreeCapacity struct{}
+
+func NewFreeCapacity() *FreeCapacity { return &FreeCapacity{} }
+
+func (p *FreeCapacity) Name() string { return "FreeCapacity" }
+
+func (p *FreeCapacity) Filter(_ *CycleState, req *SliceRequest, candidate *Candidate) bool {
+	// A mandatory cluster must remain eligible regardless of free capacity:
+	// the slice is already placed there and is not moving.
+	return candidate.Cluster == req.MandatoryCluster || candidate.FreeSlices > 0
+}
+
+func (p *FreeCapacity) Score(_ *CycleState, _ *SliceRequest, candidate *Candidate) (int64, error) {
+	return int64(candidate.FreeSlices), nil
+}
+
+// LeastLoaded scores candidates by how little of their total capacity is
+// used, favoring clusters with low utilization regardless of their raw
+// size. Weighting this above FreeCapacity spreads load more evenly across
+// unevenly sized super clusters.
+type LeastLoaded struct{}
+
+func NewLeastLoaded() *LeastLoaded { return &LeastLoaded{} }
+
+func (p *LeastLoaded) Name() string { return "LeastLoaded" }
+
+func (p *LeastLoaded) Score(_ *CycleState, _ *SliceRequest, candidate *Candidate) (int64, error) {
+	if candidate.TotalSlices <= 0 {
+		return 0, nil
+	}
+	used := candidate.TotalSlices - candidate.FreeSlices
+	return int64(100 - (used*100)/candidate.TotalSlices), nil
+}
+
+// SpreadCap enforces PlacementPolicySpread's ideal per-cluster cap: once a
+// cluster has received SpreadCapStateKey slices from the current batch, it
+// is filtered out in favor of less-loaded clusters in that same batch.
+type SpreadCap struct{}
+
+func NewSpreadCap() *SpreadCap { return &SpreadCap{} }
+
+func (p *SpreadCap) Name() string { return "SpreadCap" }
+
+func (p *SpreadCap) Filter(state *CycleState, req *SliceRequest, candidate *Candidate) bool {
+	if req.Policy != internalcache.PlacementPolicySpread || candidate.Cluster == req.MandatoryCluster {
+		return true
+	}
+	rawCap, ok := state.Read(SpreadCapStateKey)
+	if !ok {
+		return true
+	}
+	return p.placedFor(state, candidate.Cluster) < rawCap.(int)
+}
+
+func (p *SpreadCap) Reserve(state *CycleState, req *SliceRequest, cluster string) error {
+	if req.Policy != internalcache.PlacementPolicySpread {
+		return nil
+	}
+	placed := p.placedMap(state)
+	placed[cluster]++
+	state.Write(spreadPlacedStateKey, placed)
+	return nil
+}
+
+func (p *SpreadCap) Unreserve(state *CycleState, _ *SliceRequest, cluster string) {
+	placed := p.placedMap(state)
+	if placed[cluster] > 0 {
+		placed[cluster]--
+	}
+	state.Write(spreadPlacedStateKey, placed)
+}
+
+func (p *SpreadCap) placedFor(state *CycleState, cluster string) int {
+	return p.placedMap(state)[cluster]
+}
+
+func (p *SpreadCap) placedMap(state *CycleState) map[string]int {
+	raw, ok := state.Read(spreadPlacedStateKey)
+	if !ok {
+		return make(map[string]int)
+	}
+	return raw.(map[string]int)
+}
+
+// DefaultRegistry returns every built-in plugin keyed by name.
+func DefaultRegistry() map[string]Plugin {
+	return map[string]Plugin{
+		"MandatoryPlacement": NewMandatoryPlacement(),
+		"OldPlacementHint":   NewOldPlacementHint(),
+		"FreeCapacity":       NewFreeCapacity(),
+		"LeastLoaded":        NewLeastLoaded(),
+		"SpreadCap":          NewSpreadCap(),
+	}
+}
+
+// DefaultConfig enables every built-in plugin except LeastLoaded, which
+// operators can turn on (and weight) in place of or alongside FreeCapacity
+// to favor evening out utilization over raw bin-packing.
+func DefaultConfig() []PluginConfig {
+	return []PluginConfig{
+		{Name: "MandatoryPlacement", Enabled: true, Weight: 1},
+		{Name: "OldPlacementHint", Enabled: true, Weight: 1},
+		{Name: "FreeCapacity", Enabled: true, Weight: 1},
+		{Name: "LeastLoaded", Enabled: false, Weight: 1},
+		{Name: "SpreadCap", Enabled: true},
+	}
+}
+
+// NewDefaultFramework builds a Framework from DefaultConfig and
+// DefaultRegistry.
+func NewDefaultFramework() (*Framework, error) {
+	return NewFramework(DefaultConfig(), DefaultRegistry())
+}
@@ -0,0 +1,237 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cache
+
+import (
+	"fmt"
+	"sync"
+)
+
+// SuperClusterCapacity describes how many free slices a super cluster can
+// still accept.
+type SuperClusterCapacity struct {
+	Cluster     string
+	FreeSlices  int
+	TotalSlices int
+}
+
+// NamespaceSchedSnapshot is a point-in-time view of super cluster capacity
+// used to schedule the slices of a single namespace.
+type NamespaceSchedSnapshot struct {
+	Clusters []SuperClusterCapacity
+}
+
+// PodSchedSnapshot is a point-in-time view used to schedule a single pod.
+// Pods can only be placed onto the super cluster their namespace was placed
+// onto, so the snapshot simply carries that cluster set.
+type PodSchedSnapshot struct {
+	Clusters []string
+}
+
+// Cache is the scheduler's view of namespaces, pods and super cluster
+// capacity. Implementations must be safe for concurrent use.
+type Cache interface {
+	GetNamespace(key string) *Namespace
+	ListNamespaces() []*Namespace
+	AddNamespace(ns *Namespace) error
+	UpdateNamespace(old, new *Namespace) error
+	RemoveNamespace(ns *Namespace)
+	SnapshotForNamespaceSched(cur *Namespace) (*NamespaceSchedSnapshot, error)
+
+	// Begin starts a Txn that can stage several AddNamespace/UpdateNamespace
+	// calls and later Commit or Abort them as a unit.
+	Begin() Txn
+
+	// SetPreempting marks whether key's current placements are in the
+	// process of being preempted. While true, new pods must not be
+	// scheduled into the namespace.
+	SetPreempting(key string, preempting bool)
+	IsPreempting(key string) bool
+
+	GetPod(key string) *Pod
+	AddPod(pod *Pod) error
+	RemovePod(pod *Pod)
+	SnapshotForPodSched(pod *Pod) (*PodSchedSnapshot, error)
+}
+
+var _ Cache = &schedulerCache{}
+
+// schedulerCache is a simple in-memory Cache backed by a super cluster
+// capacity table supplied at construction time.
+type schedulerCache struct {
+	mu sync.RWMutex
+
+	namespaces map[string]*Namespace
+	pods       map[string]*Pod
+
+	// preempting tracks namespaces whose current placements are being
+	// descheduled on behalf of a higher-priority namespace.
+	preempting map[string]bool
+
+	// clusterCapacity is the total number of slices each super cluster can
+	// host, keyed by cluster name.
+	clusterCapacity map[string]int
+}
+
+// NewSchedulerCache creates an in-memory Cache with the given super cluster
+// capacity table.
+func NewSchedulerCache(clusterCapacity map[string]int) Cache {
+	return &schedulerCache{
+		namespaces:      make(map[string]*Namespace),
+		pods:            make(map[string]*Pod),
+		preempting:      make(map[string]bool),
+		clusterCapacity: clusterCapacity,
+	}
+}
+
+func (c *schedulerCache) GetNamespace(key string) *Namespace {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.namespaces[key]
+}
+
+func (c *schedulerCache) ListNamespaces() []*Namespace {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	out := make([]*Namespace, 0, len(c.namespaces))
+	for _, ns := range c.namespaces {
+		out = append(out, ns)
+	}
+	return out
+}
+
+func (c *schedulerCache) SetPreempting(key string, preempting bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if preempting {
+		c.preempting[key] = true
+	} else {
+		delete(c.preempting, key)
+	}
+}
+
+func (c *schedulerCache) IsPreempting(key string) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.preempting[key]
+}
+
+func (c *schedulerCache) AddNamespace(ns *Namespace) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.namespaces[ns.GetKey()]; ok {
+		return fmt.Errorf("namespace %s already exists in cache", ns.GetKey())
+	}
+	c.namespaces[ns.GetKey()] = ns
+	return nil
+}
+
+func (c *schedulerCache) UpdateNamespace(old, new *Namespace) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.namespaces[old.GetKey()]; !ok {
+		return fmt.Errorf("namespace %s does not exist in cache", old.GetKey())
+	}
+	c.namespaces[new.GetKey()] = new
+	return nil
+}
+
+func (c *schedulerCache) RemoveNamespace(ns *Namespace) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.namespaces, ns.GetKey())
+	delete(c.preempting, ns.GetKey())
+}
+
+func (c *schedulerCache) Begin() Txn {
+	return &namespaceTxn{cache: c}
+}
+
+// forceSetNamespace overwrites the cached entry for ns's key without the
+// existence checks AddNamespace/UpdateNamespace perform. It exists solely so
+// a Txn can restore the prior namespace state on Abort.
+func (c *schedulerCache) forceSetNamespace(ns *Namespace) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.namespaces[ns.GetKey()] = ns
+}
+
+func (c *schedulerCache) usedSlices() map[string]int {
+	used := make(map[string]int)
+	for _, ns := range c.namespaces {
+		for cluster, num := range ns.GetPlacementMap() {
+			used[cluster] += num
+		}
+	}
+	return used
+}
+
+func (c *schedulerCache) SnapshotForNamespaceSched(cur *Namespace) (*NamespaceSchedSnapshot, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	used := c.usedSlices()
+	if cur != nil {
+		for cluster, num := range cur.GetPlacementMap() {
+			used[cluster] -= num
+		}
+	}
+
+	snapshot := &NamespaceSchedSnapshot{}
+	for cluster, capacity := range c.clusterCapacity {
+		snapshot.Clusters = append(snapshot.Clusters, SuperClusterCapacity{
+			Cluster:     cluster,
+			FreeSlices:  capacity - used[cluster],
+			TotalSlices: capacity,
+		})
+	}
+	return snapshot, nil
+}
+
+func (c *schedulerCache) GetPod(key string) *Pod {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.pods[key]
+}
+
+func (c *schedulerCache) AddPod(pod *Pod) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.pods[pod.GetKey()] = pod
+	return nil
+}
+
+func (c *schedulerCache) RemovePod(pod *Pod) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.pods, pod.GetKey())
+}
+
+func (c *schedulerCache) SnapshotForPodSched(pod *Pod) (*PodSchedSnapshot, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	ns, ok := c.namespaces[pod.GetNamespaceKey()]
+	if !ok {
+		return nil, fmt.Errorf("namespace %s has not been scheduled", pod.GetNamespaceKey())
+	}
+	snapshot := &PodSchedSnapshot{}
+	for cluster := range ns.GetPlacementMap() {
+		snapshot.Clusters = append(snapshot.Clusters, cluster)
+	}
+	return snapshot, nil
+}
@@ -0,0 +1,33 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cache
+
+// NamespaceGroup is a set of namespaces that should be scheduled together,
+// mirroring Volcano's PodGroup: the group is only considered successfully
+// scheduled once at least MinMember of its Members have been placed. It is
+// used for gang-scheduling namespaces whose workloads only make sense if a
+// minimum number of them land on super clusters together.
+type NamespaceGroup struct {
+	Members   []*Namespace
+	MinMember int
+}
+
+// NewNamespaceGroup creates a NamespaceGroup requiring at least minMember of
+// members to be placed for the group to be considered scheduled.
+func NewNamespaceGroup(members []*Namespace, minMember int) *NamespaceGroup {
+	return &NamespaceGroup{Members: members, MinMember: minMember}
+}
@@ -0,0 +1,145 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cache
+
+// Namespace is the internal scheduling representation of a tenant namespace
+// and its resource quota slices. Each slice is a fixed-size unit of quota
+// that is placed onto a single super cluster.
+type Namespace struct {
+	key string
+
+	// quotaSlice is the size, in quota units, of a single slice.
+	quotaSlice int
+	// totalSlices is the number of slices this namespace currently requests.
+	totalSlices int
+
+	// placementMap records, for each super cluster, how many slices of this
+	// namespace are currently placed there.
+	placementMap map[string]int
+
+	// priority is the tenant priority used to order preemption: a namespace
+	// may only preempt namespaces of strictly lower priority.
+	priority int32
+
+	// placementPolicy controls how GetSlicesToSchedule/ScheduleNamespaceSlices
+	// distribute this namespace's slices across super clusters. One of
+	// PlacementPolicyPack (the default) or PlacementPolicySpread.
+	placementPolicy string
+}
+
+const (
+	// PlacementPolicyPack bin-packs slices onto the super clusters that
+	// already have the most free capacity. This is the default policy.
+	PlacementPolicyPack = "Pack"
+	// PlacementPolicySpread distributes slices as evenly as possible across
+	// the available super clusters, up to ceil(TotalSlices/numClusters) per
+	// cluster.
+	PlacementPolicySpread = "Spread"
+)
+
+// PlacementPolicyAnnotation is the VirtualCluster annotation tenants should
+// set to request a non-default PlacementPolicy. Nothing in this package reads
+// it off a live VirtualCluster object; a reconciler that does so is expected
+// to pass the resulting value into NewNamespace's placementPolicy parameter.
+const PlacementPolicyAnnotation = "tenancy.x-k8s.io/namespace-placement-policy"
+
+// NewNamespace creates a Namespace with the given key, slice sizing,
+// scheduling priority and placement policy. An empty policy is treated as
+// PlacementPolicyPack.
+func NewNamespace(key string, quotaSlice, totalSlices int, priority int32, placementPolicy string) *Namespace {
+	if placementPolicy == "" {
+		placementPolicy = PlacementPolicyPack
+	}
+	return &Namespace{
+		key:             key,
+		quotaSlice:      quotaSlice,
+		totalSlices:     totalSlices,
+		placementMap:    make(map[string]int),
+		priority:        priority,
+		placementPolicy: placementPolicy,
+	}
+}
+
+func (n *Namespace) GetKey() string {
+	return n.key
+}
+
+// GetQuotaSlice returns the fixed size of a single slice for this namespace.
+func (n *Namespace) GetQuotaSlice() int {
+	return n.quotaSlice
+}
+
+// GetTotalSlices returns the total number of slices this namespace requests.
+func (n *Namespace) GetTotalSlices() int {
+	return n.totalSlices
+}
+
+// SetTotalSlices overwrites the total number of slices this namespace
+// requests, e.g. after deriving it from the tenant's current ResourceQuota.
+func (n *Namespace) SetTotalSlices(total int) {
+	n.totalSlices = total
+}
+
+// GetPlacementMap returns a copy of the current super-cluster placements.
+func (n *Namespace) GetPlacementMap() map[string]int {
+	placements := make(map[string]int, len(n.placementMap))
+	for cluster, num := range n.placementMap {
+		placements[cluster] = num
+	}
+	return placements
+}
+
+// SetNewPlacements overwrites the placement map with a freshly computed one.
+func (n *Namespace) SetNewPlacements(placements map[string]int) {
+	n.placementMap = placements
+}
+
+// GetPriority returns the tenant priority of this namespace.
+func (n *Namespace) GetPriority() int32 {
+	return n.priority
+}
+
+// GetPlacementPolicy returns the policy used to distribute this namespace's
+// slices across super clusters.
+func (n *Namespace) GetPlacementPolicy() string {
+	return n.placementPolicy
+}
+
+// Comparable reports whether n and other describe the same quota shape, i.e.
+// whether other can be scheduled as an update of n without a full reschedule.
+func (n *Namespace) Comparable(other *Namespace) bool {
+	if other == nil {
+		return false
+	}
+	return n.key == other.key && n.quotaSlice == other.quotaSlice
+}
+
+// DeepCopy returns a deep copy of the Namespace.
+func (n *Namespace) DeepCopy() *Namespace {
+	if n == nil {
+		return nil
+	}
+	out := &Namespace{
+		key:             n.key,
+		quotaSlice:      n.quotaSlice,
+		totalSlices:     n.totalSlices,
+		priority:        n.priority,
+		placementPolicy: n.placementPolicy,
+	}
+	out.placementMap = n.GetPlacementMap()
+	return out
+}
@@ -0,0 +1,61 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cache
+
+// Pod is the internal scheduling representation of a tenant pod awaiting
+// placement onto the super cluster that hosts its namespace's slice.
+type Pod struct {
+	key          string
+	namespaceKey string
+
+	cluster string
+}
+
+// NewPod creates a Pod with the given key, belonging to the namespace
+// identified by namespaceKey.
+func NewPod(key, namespaceKey string) *Pod {
+	return &Pod{key: key, namespaceKey: namespaceKey}
+}
+
+func (p *Pod) GetKey() string {
+	return p.key
+}
+
+// GetNamespaceKey returns the key of the namespace this pod belongs to.
+func (p *Pod) GetNamespaceKey() string {
+	return p.namespaceKey
+}
+
+// GetCluster returns the super cluster this pod has been placed onto, or
+// the empty string if it has not been scheduled yet.
+func (p *Pod) GetCluster() string {
+	return p.cluster
+}
+
+// SetCluster records the super cluster this pod has been placed onto.
+func (p *Pod) SetCluster(cluster string) {
+	p.cluster = cluster
+}
+
+// DeepCopy returns a deep copy of the Pod.
+func (p *Pod) DeepCopy() *Pod {
+	if p == nil {
+		return nil
+	}
+	out := *p
+	return &out
+}
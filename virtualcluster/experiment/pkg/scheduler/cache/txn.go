@@ -0,0 +1,87 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cache
+
+// Txn stages a batch of namespace placement mutations against a Cache so
+// they can be applied atomically: either every staged mutation is kept via
+// Commit, or none of them are, via Abort. This lets callers that schedule
+// several namespaces together (e.g. a gang of namespaces that must all be
+// placed or none at all) try the whole batch before deciding whether any of
+// it should stick.
+type Txn interface {
+	AddNamespace(ns *Namespace) error
+	UpdateNamespace(old, new *Namespace) error
+	// Commit keeps every mutation staged so far. It never fails: the
+	// mutations have already been applied to the underlying cache: Commit
+	// simply stops Abort from being able to undo them.
+	Commit()
+	// Abort undoes every mutation staged so far, restoring the cache to the
+	// state it was in before the transaction began.
+	Abort()
+}
+
+var _ Txn = &namespaceTxn{}
+
+type namespaceOp struct {
+	added   *Namespace
+	updated *Namespace
+	restore *Namespace
+}
+
+type namespaceTxn struct {
+	cache *schedulerCache
+	ops   []namespaceOp
+	done  bool
+}
+
+func (t *namespaceTxn) AddNamespace(ns *Namespace) error {
+	if err := t.cache.AddNamespace(ns); err != nil {
+		return err
+	}
+	t.ops = append(t.ops, namespaceOp{added: ns})
+	return nil
+}
+
+func (t *namespaceTxn) UpdateNamespace(old, new *Namespace) error {
+	if err := t.cache.UpdateNamespace(old, new); err != nil {
+		return err
+	}
+	t.ops = append(t.ops, namespaceOp{updated: new, restore: old})
+	return nil
+}
+
+func (t *namespaceTxn) Commit() {
+	t.done = true
+	t.ops = nil
+}
+
+func (t *namespaceTxn) Abort() {
+	if t.done {
+		return
+	}
+	for i := len(t.ops) - 1; i >= 0; i-- {
+		op := t.ops[i]
+		switch {
+		case op.added != nil:
+			t.cache.RemoveNamespace(op.added)
+		case op.updated != nil:
+			t.cache.forceSetNamespace(op.restore)
+		}
+	}
+	t.done = true
+	t.ops = nil
+}
@@ -0,0 +1,64 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cache
+
+import "testing"
+
+func TestTxnCommitKeepsStagedMutations(t *testing.T) {
+	c := NewSchedulerCache(map[string]int{"c1": 10})
+	txn := c.Begin()
+
+	ns := NewNamespace("ns", 1, 1, 0, "")
+	if err := txn.AddNamespace(ns); err != nil {
+		t.Fatalf("AddNamespace: %v", err)
+	}
+	txn.Commit()
+
+	if got := c.GetNamespace("ns"); got != ns {
+		t.Fatalf("expected namespace to remain in cache after Commit, got %v", got)
+	}
+}
+
+func TestTxnAbortUndoesStagedMutations(t *testing.T) {
+	c := NewSchedulerCache(map[string]int{"c1": 10})
+
+	existing := NewNamespace("ns", 1, 1, 0, "")
+	if err := c.AddNamespace(existing); err != nil {
+		t.Fatalf("seeding namespace: %v", err)
+	}
+
+	txn := c.Begin()
+	updated := existing.DeepCopy()
+	updated.SetNewPlacements(map[string]int{"c1": 1})
+	if err := txn.UpdateNamespace(existing, updated); err != nil {
+		t.Fatalf("UpdateNamespace: %v", err)
+	}
+
+	added := NewNamespace("other", 1, 1, 0, "")
+	if err := txn.AddNamespace(added); err != nil {
+		t.Fatalf("AddNamespace: %v", err)
+	}
+
+	txn.Abort()
+
+	if got := c.GetNamespace("ns"); got != existing {
+		t.Fatalf("expected UpdateNamespace to be rolled back to the original namespace, got %v", got)
+	}
+	if got := c.GetNamespace("other"); got != nil {
+		t.Fatalf("expected AddNamespace to be rolled back, got %v", got)
+	}
+}
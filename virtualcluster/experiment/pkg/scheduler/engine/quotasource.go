@@ -0,0 +1,108 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package engine
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	clientgocache "k8s.io/client-go/tools/cache"
+)
+
+// QuotaSource lists the ResourceQuota objects governing a tenant namespace,
+// keyed the same way as the internalcache.Namespace it backs. ScheduleNamespace
+// consults it to keep slice sizing in sync with quota changes.
+type QuotaSource interface {
+	ListQuotas(nsKey string) ([]corev1.ResourceQuota, error)
+}
+
+// SliceGranularity is the amount of hard.cpu / hard.memory quota a single
+// slice represents. A namespace's derived slice count is the larger of
+// ceil(hard.cpu/CPU) and ceil(hard.memory/Memory); a zero Quantity excludes
+// that resource from the computation.
+type SliceGranularity struct {
+	CPU    resource.Quantity
+	Memory resource.Quantity
+}
+
+var _ QuotaSource = &InformerQuotaSource{}
+
+// InformerQuotaSource is a QuotaSource backed by a shared informer's local
+// index, so quota lookups don't hit the API server on every schedule call.
+type InformerQuotaSource struct {
+	indexer clientgocache.Indexer
+}
+
+// NewInformerQuotaSource wraps informer's indexer as a QuotaSource. The
+// informer must be indexed by namespace under clientgocache.NamespaceIndex.
+func NewInformerQuotaSource(informer clientgocache.SharedIndexInformer) *InformerQuotaSource {
+	return &InformerQuotaSource{indexer: informer.GetIndexer()}
+}
+
+func (s *InformerQuotaSource) ListQuotas(nsKey string) ([]corev1.ResourceQuota, error) {
+	items, err := s.indexer.ByIndex(clientgocache.NamespaceIndex, nsKey)
+	if err != nil {
+		return nil, err
+	}
+	quotas := make([]corev1.ResourceQuota, 0, len(items))
+	for _, obj := range items {
+		quota, ok := obj.(*corev1.ResourceQuota)
+		if !ok {
+			return nil, fmt.Errorf("unexpected object type %T in ResourceQuota informer indexer", obj)
+		}
+		quotas = append(quotas, *quota)
+	}
+	return quotas, nil
+}
+
+// deriveTotalSlices sums the hard.cpu and hard.memory of every ResourceQuota
+// in quotas and converts the larger of the two into a slice count using
+// granularity. It returns 0 if quotas is empty.
+func deriveTotalSlices(quotas []corev1.ResourceQuota, granularity SliceGranularity) int {
+	var cpu, memory resource.Quantity
+	for _, quota := range quotas {
+		if hard, ok := quota.Spec.Hard[corev1.ResourceCPU]; ok {
+			cpu.Add(hard)
+		}
+		if hard, ok := quota.Spec.Hard[corev1.ResourceMemory]; ok {
+			memory.Add(hard)
+		}
+	}
+
+	slices := 0
+	if granularity.CPU.Sign() > 0 {
+		slices = ceilDiv(cpu, granularity.CPU)
+	}
+	if granularity.Memory.Sign() > 0 {
+		if bySlices := ceilDiv(memory, granularity.Memory); bySlices > slices {
+			slices = bySlices
+		}
+	}
+	return slices
+}
+
+// ceilDiv returns ceil(total/unit) using millivalues so sub-unit quantities
+// (e.g. "500m" CPU) divide correctly.
+func ceilDiv(total, unit resource.Quantity) int {
+	u := unit.MilliValue()
+	if u <= 0 {
+		return 0
+	}
+	t := total.MilliValue()
+	return int((t + u - 1) / u)
+}
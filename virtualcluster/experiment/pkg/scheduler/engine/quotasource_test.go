@@ -0,0 +1,73 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package engine
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func quotaWith(cpu, memory string) corev1.ResourceQuota {
+	hard := corev1.ResourceList{}
+	if cpu != "" {
+		hard[corev1.ResourceCPU] = resource.MustParse(cpu)
+	}
+	if memory != "" {
+		hard[corev1.ResourceMemory] = resource.MustParse(memory)
+	}
+	return corev1.ResourceQuota{Spec: corev1.ResourceQuotaSpec{Hard: hard}}
+}
+
+func TestDeriveTotalSlicesTakesLargerOfCPUAndMemory(t *testing.T) {
+	granularity := SliceGranularity{CPU: resource.MustParse("1"), Memory: resource.MustParse("2Gi")}
+	quotas := []corev1.ResourceQuota{quotaWith("2500m", "2Gi")}
+
+	// ceil(2500m/1) = 3 slices by CPU, ceil(2Gi/2Gi) = 1 slice by memory.
+	if got := deriveTotalSlices(quotas, granularity); got != 3 {
+		t.Fatalf("expected 3 slices, got %d", got)
+	}
+}
+
+func TestDeriveTotalSlicesSumsAcrossMultipleQuotas(t *testing.T) {
+	granularity := SliceGranularity{CPU: resource.MustParse("1")}
+	quotas := []corev1.ResourceQuota{quotaWith("500m", ""), quotaWith("750m", "")}
+
+	// 500m + 750m = 1250m, ceil(1250m/1000m) = 2.
+	if got := deriveTotalSlices(quotas, granularity); got != 2 {
+		t.Fatalf("expected 2 slices, got %d", got)
+	}
+}
+
+func TestDeriveTotalSlicesIgnoresZeroGranularityResource(t *testing.T) {
+	granularity := SliceGranularity{CPU: resource.MustParse("1")}
+	quotas := []corev1.ResourceQuota{quotaWith("500m", "100Gi")}
+
+	// Memory granularity is unset (zero), so it must not factor in even
+	// though it would imply a much larger slice count.
+	if got := deriveTotalSlices(quotas, granularity); got != 1 {
+		t.Fatalf("expected 1 slice, got %d", got)
+	}
+}
+
+func TestDeriveTotalSlicesNoQuotas(t *testing.T) {
+	granularity := SliceGranularity{CPU: resource.MustParse("1")}
+	if got := deriveTotalSlices(nil, granularity); got != 0 {
+		t.Fatalf("expected 0 slices for no quotas, got %d", got)
+	}
+}
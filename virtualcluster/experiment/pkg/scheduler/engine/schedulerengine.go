@@ -18,11 +18,13 @@ package engine
 
 import (
 	"fmt"
+	"sort"
 	"sync"
 
 	"k8s.io/klog/v2"
 
 	"sigs.k8s.io/cluster-api-provider-nested/virtualcluster/experiment/pkg/scheduler/algorithm"
+	"sigs.k8s.io/cluster-api-provider-nested/virtualcluster/experiment/pkg/scheduler/algorithm/framework"
 	internalcache "sigs.k8s.io/cluster-api-provider-nested/virtualcluster/experiment/pkg/scheduler/cache"
 	"sigs.k8s.io/cluster-api-provider-nested/virtualcluster/experiment/pkg/scheduler/util"
 )
@@ -33,6 +35,59 @@ type Engine interface {
 	DeScheduleNamespace(key string) error
 	SchedulePod(pod *internalcache.Pod) (*internalcache.Pod, error)
 	DeSchedulePod(key string) error
+
+	// PreemptNamespace selects lower-priority namespaces on one super
+	// cluster whose placements must be descheduled and rescheduled to free
+	// enough slices for key (see selectPreemptionVictims), marks them as being preempted, and returns their
+	// keys. Callers are responsible for actually descheduling/rescheduling
+	// the returned namespaces.
+	PreemptNamespace(key string) ([]string, error)
+
+	// ScheduleNamespaceGroup schedules every namespace in group one at a
+	// time against the live cache, so each member's snapshot already
+	// reflects every earlier member's placement from this same pass (no
+	// two members can be double-booked onto the same freed-up capacity),
+	// and commits the resulting placements only if at least
+	// group.MinMember of them could be placed; otherwise every mutation
+	// staged during the pass is rolled back.
+	ScheduleNamespaceGroup(group *internalcache.NamespaceGroup) (map[string]*internalcache.Namespace, error)
+
+	// RebalanceNamespace recomputes key's placements from scratch under its
+	// current placement policy and returns the diff against its live
+	// placements, without mutating the cache. Callers can use the diff's
+	// churn to decide whether adopting it is worth the eviction cost.
+	RebalanceNamespace(key string) (*PlacementDiff, error)
+}
+
+// PlacementDiff is the result of a trial re-placement of a namespace: its
+// live placements (Old) versus what a fresh placement under the current
+// policy would look like (New).
+type PlacementDiff struct {
+	Old map[string]int
+	New map[string]int
+}
+
+// Churn is the number of slices that would need to move between super
+// clusters to go from Old to New.
+func (d *PlacementDiff) Churn() int {
+	churn := 0
+	for cluster, oldNum := range d.Old {
+		if newNum := d.New[cluster]; newNum < oldNum {
+			churn += oldNum - newNum
+		}
+	}
+	return churn
+}
+
+// GroupScheduleError is returned by ScheduleNamespaceGroup when fewer than
+// MinMember namespaces in the group could be placed, listing why each
+// unplaced namespace failed.
+type GroupScheduleError struct {
+	Failures map[string]error
+}
+
+func (e *GroupScheduleError) Error() string {
+	return fmt.Sprintf("namespace group scheduling failed, %d of the group's namespace(s) could not be placed: %v", len(e.Failures), e.Failures)
 }
 
 var _ Engine = &schedulerEngine{}
@@ -41,31 +96,79 @@ type schedulerEngine struct {
 	mu sync.RWMutex
 
 	cache internalcache.Cache
+
+	// quotaSource and granularity derive a namespace's TotalSlices from its
+	// tenant ResourceQuota objects. Both are nil/zero when quota-aware
+	// sizing is disabled, in which case namespaces keep whatever
+	// TotalSlices the caller set.
+	quotaSource QuotaSource
+	granularity SliceGranularity
+
+	// framework runs the Filter/Score/NormalizeScore/Reserve plugin
+	// pipeline every ScheduleNamespace/ScheduleNamespaceGroup/
+	// RebalanceNamespace/SchedulePod call schedules slices and pods with.
+	framework *framework.Framework
 }
 
-func NewSchedulerEngine(schedulerCache internalcache.Cache) Engine {
-	return &schedulerEngine{cache: schedulerCache}
+// NewSchedulerEngine creates a SchedulerEngine using the built-in plugin
+// pipeline (framework.NewDefaultFramework). Use NewSchedulerEngineWithPlugins
+// to load a custom plugin configuration instead.
+func NewSchedulerEngine(schedulerCache internalcache.Cache) (Engine, error) {
+	fw, err := framework.NewDefaultFramework()
+	if err != nil {
+		return nil, err
+	}
+	return &schedulerEngine{cache: schedulerCache, framework: fw}, nil
+}
+
+// NewQuotaAwareSchedulerEngine creates a SchedulerEngine that recomputes
+// every namespace's TotalSlices from its tenant ResourceQuota objects,
+// observed through quotaSource, on every ScheduleNamespace call, using the
+// built-in plugin pipeline. A namespace whose quotas derive to zero slices
+// keeps the TotalSlices the caller set.
+func NewQuotaAwareSchedulerEngine(schedulerCache internalcache.Cache, quotaSource QuotaSource, granularity SliceGranularity) (Engine, error) {
+	fw, err := framework.NewDefaultFramework()
+	if err != nil {
+		return nil, err
+	}
+	return &schedulerEngine{cache: schedulerCache, quotaSource: quotaSource, granularity: granularity, framework: fw}, nil
+}
+
+// NewSchedulerEngineWithPlugins creates a SchedulerEngine whose plugin
+// pipeline is built from configs against framework.DefaultRegistry, letting
+// operators enable/disable/weight the built-in plugins (e.g. to favor
+// framework.LeastLoaded over framework.FreeCapacity) without forking the
+// engine.
+func NewSchedulerEngineWithPlugins(schedulerCache internalcache.Cache, configs []framework.PluginConfig) (Engine, error) {
+	fw, err := framework.NewFramework(configs, framework.DefaultRegistry())
+	if err != nil {
+		return nil, err
+	}
+	return &schedulerEngine{cache: schedulerCache, framework: fw}, nil
 }
 
 func GetSlicesToSchedule(namespace *internalcache.Namespace, oldPlacements map[string]int) algorithm.SliceInfoArray {
 	key := namespace.GetKey()
 	slicesToSchedule := make(algorithm.SliceInfoArray, 0)
 	size := namespace.GetQuotaSlice()
+	policy := namespace.GetPlacementPolicy()
 
 	remainingToSchedule := namespace.GetTotalSlices()
-	// handle slices that have mandatory placements
-	// TODO: sorting the mandatory placements
-	for cluster, num := range namespace.GetPlacementMap() {
+	// handle slices that have mandatory placements, most-loaded cluster
+	// first, so that a reduced quota drops slices from the least-loaded
+	// cluster rather than an arbitrary one.
+	for _, placement := range sortedPlacements(namespace.GetPlacementMap()) {
 		if remainingToSchedule == 0 {
 			// it is possible when namespace quota is reduced
 			break
 		}
+		cluster, num := placement.cluster, placement.count
 		mandatory := util.Min(num, remainingToSchedule)
 		if val, ok := oldPlacements[cluster]; ok {
 			used := util.Min(val, mandatory)
 			oldPlacements[cluster] = val - used
 		}
-		slicesToSchedule.Repeat(mandatory, key, size, cluster, "")
+		slicesToSchedule.Repeat(mandatory, key, size, cluster, "", policy)
 		remainingToSchedule = remainingToSchedule - mandatory
 	}
 
@@ -76,13 +179,33 @@ func GetSlicesToSchedule(namespace *internalcache.Namespace, oldPlacements map[s
 			break
 		}
 		hinted := util.Min(num, remainingToSchedule)
-		slicesToSchedule.Repeat(hinted, key, size, "", cluster)
+		slicesToSchedule.Repeat(hinted, key, size, "", cluster, policy)
 		remainingToSchedule = remainingToSchedule - hinted
 	}
-	slicesToSchedule.Repeat(remainingToSchedule, key, size, "", "")
+	slicesToSchedule.Repeat(remainingToSchedule, key, size, "", "", policy)
 	return slicesToSchedule
 }
 
+// clusterPlacement pairs a super cluster with how many slices of a
+// namespace are currently placed there.
+type clusterPlacement struct {
+	cluster string
+	count   int
+}
+
+// sortedPlacements returns placementMap's entries sorted by slice count,
+// most-loaded cluster first.
+func sortedPlacements(placementMap map[string]int) []clusterPlacement {
+	placements := make([]clusterPlacement, 0, len(placementMap))
+	for cluster, num := range placementMap {
+		placements = append(placements, clusterPlacement{cluster, num})
+	}
+	sort.Slice(placements, func(i, j int) bool {
+		return placements[i].count > placements[j].count
+	})
+	return placements
+}
+
 func GetNewPlacement(slices algorithm.SliceInfoArray) (map[string]int, error) {
 	newPlacement := make(map[string]int)
 	for _, each := range slices {
@@ -104,8 +227,19 @@ func (e *schedulerEngine) ScheduleNamespace(namespace *internalcache.Namespace)
 	// All slices have to be re-examined against the cache since some placed clusters may become invalid. However,
 	// we can use old placement as hint for new placement. The idea is that we should maximally avoid
 	// changing the placement clusters since the overhead of switching super clusters is nontrivial.
-	var oldPlacements map[string]int
 	key := namespace.GetKey()
+	if e.quotaSource != nil {
+		quotas, err := e.quotaSource.ListQuotas(key)
+		if err != nil {
+			return nil, err
+		}
+		if derived := deriveTotalSlices(quotas, e.granularity); derived > 0 {
+			namespace = namespace.DeepCopy()
+			namespace.SetTotalSlices(derived)
+		}
+	}
+
+	var oldPlacements map[string]int
 	curState := e.cache.GetNamespace(key)
 	if curState != nil {
 		if !namespace.Comparable(curState) {
@@ -114,29 +248,335 @@ func (e *schedulerEngine) ScheduleNamespace(namespace *internalcache.Namespace)
 		oldPlacements = curState.GetPlacementMap()
 	}
 
-	var newPlacement map[string]int
-	var snapshot *internalcache.NamespaceSchedSnapshot
-	var err error
 	slicesToSchedule := GetSlicesToSchedule(namespace, oldPlacements)
-	snapshot, err = e.cache.SnapshotForNamespaceSched(curState)
+	snapshot, err := e.cache.SnapshotForNamespaceSched(curState)
 	if err != nil {
 		return nil, err
 	}
-	slicesToSchedule = algorithm.ScheduleNamespaceSlices(slicesToSchedule, snapshot)
+	slicesToSchedule = algorithm.ScheduleNamespaceSlices(slicesToSchedule, snapshot, e.framework)
+	newPlacement, err := GetNewPlacement(slicesToSchedule)
+	if err == nil {
+		ret := namespace.DeepCopy()
+		ret.SetNewPlacements(newPlacement)
+		if curState != nil {
+			err = e.cache.UpdateNamespace(curState, ret)
+		} else {
+			err = e.cache.AddNamespace(ret)
+		}
+		return ret, err
+	}
+
+	// No super cluster had enough free slices. Try to preempt lower-priority
+	// namespaces to make room for the slices this pass actually failed to
+	// place (not namespace.GetTotalSlices() minus its own placement map,
+	// which ignores however much of the shortfall free capacity already
+	// covered). Their eviction from the targeted cluster is staged in the
+	// same Txn as our own placement, so if we still can't be scheduled after
+	// preempting, none of it sticks and their slices aren't freed for
+	// nothing.
+	shortfall := countUnplaced(slicesToSchedule)
+	result, preemptErr := e.selectAndMarkVictimsLocked(namespace, shortfall)
+	if preemptErr != nil {
+		return nil, preemptErr
+	}
+	klog.Infof("preempting namespaces %v on cluster %s to make room for %s", namespaceKeys(result.victims), result.cluster, key)
+
+	txn := e.cache.Begin()
+	for _, victim := range result.victims {
+		placements := victim.GetPlacementMap()
+		if remaining := placements[result.cluster] - result.victimFreed[victim.GetKey()]; remaining > 0 {
+			placements[result.cluster] = remaining
+		} else {
+			delete(placements, result.cluster)
+		}
+		evicted := victim.DeepCopy()
+		evicted.SetNewPlacements(placements)
+		if err := txn.UpdateNamespace(victim, evicted); err != nil {
+			txn.Abort()
+			e.unmarkPreempting(result.victims)
+			return nil, err
+		}
+	}
+
+	for i := range snapshot.Clusters {
+		if snapshot.Clusters[i].Cluster == result.cluster {
+			snapshot.Clusters[i].FreeSlices += result.freed
+		}
+	}
+	slicesToSchedule = algorithm.ScheduleNamespaceSlices(slicesToSchedule, snapshot, e.framework)
 	newPlacement, err = GetNewPlacement(slicesToSchedule)
 	if err != nil {
+		txn.Abort()
+		e.unmarkPreempting(result.victims)
 		return nil, err
 	}
+
 	ret := namespace.DeepCopy()
 	ret.SetNewPlacements(newPlacement)
-
-	// update the cache
 	if curState != nil {
-		err = e.cache.UpdateNamespace(curState, ret)
+		err = txn.UpdateNamespace(curState, ret)
 	} else {
-		err = e.cache.AddNamespace(ret)
+		err = txn.AddNamespace(ret)
 	}
-	return ret, err
+	if err != nil {
+		txn.Abort()
+		e.unmarkPreempting(result.victims)
+		return nil, err
+	}
+	txn.Commit()
+	e.unmarkPreempting(result.victims)
+	return ret, nil
+}
+
+// ScheduleNamespaceGroup implements Engine.
+func (e *schedulerEngine) ScheduleNamespaceGroup(group *internalcache.NamespaceGroup) (map[string]*internalcache.Namespace, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	txn := e.cache.Begin()
+	placed := make(map[string]*internalcache.Namespace)
+	failures := make(map[string]error)
+
+	for _, namespace := range group.Members {
+		key := namespace.GetKey()
+		curState := e.cache.GetNamespace(key)
+		var oldPlacements map[string]int
+		if curState != nil {
+			if !namespace.Comparable(curState) {
+				failures[key] = fmt.Errorf("updating namespace with quotaslcie change is not supported")
+				continue
+			}
+			oldPlacements = curState.GetPlacementMap()
+		}
+
+		slicesToSchedule := GetSlicesToSchedule(namespace, oldPlacements)
+		snapshot, err := e.cache.SnapshotForNamespaceSched(curState)
+		if err != nil {
+			failures[key] = err
+			continue
+		}
+		slicesToSchedule = algorithm.ScheduleNamespaceSlices(slicesToSchedule, snapshot, e.framework)
+		newPlacement, err := GetNewPlacement(slicesToSchedule)
+		if err != nil {
+			failures[key] = err
+			continue
+		}
+
+		ret := namespace.DeepCopy()
+		ret.SetNewPlacements(newPlacement)
+		if curState != nil {
+			err = txn.UpdateNamespace(curState, ret)
+		} else {
+			err = txn.AddNamespace(ret)
+		}
+		if err != nil {
+			failures[key] = err
+			continue
+		}
+		placed[key] = ret
+	}
+
+	if len(placed) < group.MinMember {
+		txn.Abort()
+		return nil, &GroupScheduleError{Failures: failures}
+	}
+
+	txn.Commit()
+	return placed, nil
+}
+
+// RebalanceNamespace implements Engine.
+func (e *schedulerEngine) RebalanceNamespace(key string) (*PlacementDiff, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	curState := e.cache.GetNamespace(key)
+	if curState == nil {
+		return nil, fmt.Errorf("namespace %s is not known to the scheduler", key)
+	}
+
+	// Unlike ScheduleNamespace, a rebalance treats every slice as
+	// unconstrained so the current policy is free to redistribute them,
+	// rather than pinning them to their existing cluster as mandatory.
+	slicesToSchedule := make(algorithm.SliceInfoArray, 0)
+	slicesToSchedule.Repeat(curState.GetTotalSlices(), key, curState.GetQuotaSlice(), "", "", curState.GetPlacementPolicy())
+
+	snapshot, err := e.cache.SnapshotForNamespaceSched(curState)
+	if err != nil {
+		return nil, err
+	}
+	slicesToSchedule = algorithm.ScheduleNamespaceSlices(slicesToSchedule, snapshot, e.framework)
+	newPlacement, err := GetNewPlacement(slicesToSchedule)
+	if err != nil {
+		return nil, err
+	}
+	return &PlacementDiff{Old: curState.GetPlacementMap(), New: newPlacement}, nil
+}
+
+// PreemptNamespace implements Engine.
+func (e *schedulerEngine) PreemptNamespace(key string) ([]string, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	preemptor := e.cache.GetNamespace(key)
+	if preemptor == nil {
+		return nil, fmt.Errorf("namespace %s is not known to the scheduler", key)
+	}
+	shortfall, err := e.unplacedSliceCount(preemptor)
+	if err != nil {
+		return nil, err
+	}
+	result, err := e.selectAndMarkVictimsLocked(preemptor, shortfall)
+	if err != nil {
+		return nil, err
+	}
+	return namespaceKeys(result.victims), nil
+}
+
+// unplacedSliceCount runs namespace's slices through a scheduling dry run
+// against the live cache and returns how many could not be placed, i.e. the
+// number of slices preemption needs to free capacity for. Callers must hold
+// e.mu.
+func (e *schedulerEngine) unplacedSliceCount(namespace *internalcache.Namespace) (int, error) {
+	curState := e.cache.GetNamespace(namespace.GetKey())
+	var oldPlacements map[string]int
+	if curState != nil {
+		oldPlacements = curState.GetPlacementMap()
+	}
+	slices := GetSlicesToSchedule(namespace, oldPlacements)
+	snapshot, err := e.cache.SnapshotForNamespaceSched(curState)
+	if err != nil {
+		return 0, err
+	}
+	slices = algorithm.ScheduleNamespaceSlices(slices, snapshot, e.framework)
+	return countUnplaced(slices), nil
+}
+
+// countUnplaced returns how many slices in slices failed to be placed.
+func countUnplaced(slices algorithm.SliceInfoArray) int {
+	unplaced := 0
+	for _, slice := range slices {
+		if slice.Err != nil {
+			unplaced++
+		}
+	}
+	return unplaced
+}
+
+// preemptionResult describes the outcome of a preemption pass: the super
+// cluster it targeted, the victim namespaces chosen, the number of slices
+// their eviction frees on that cluster in total, and how many of those
+// slices came from each individual victim.
+type preemptionResult struct {
+	cluster     string
+	victims     []*internalcache.Namespace
+	freed       int
+	victimFreed map[string]int
+}
+
+// selectAndMarkVictimsLocked picks victims that together free at least
+// shortfall slices for preemptor and marks them as being preempted in the
+// cache. Callers must hold e.mu.
+func (e *schedulerEngine) selectAndMarkVictimsLocked(preemptor *internalcache.Namespace, shortfall int) (*preemptionResult, error) {
+	result, err := selectPreemptionVictims(preemptor, shortfall, e.cache.ListNamespaces())
+	if err != nil {
+		return nil, err
+	}
+	for _, victim := range result.victims {
+		e.cache.SetPreempting(victim.GetKey(), true)
+	}
+	return result, nil
+}
+
+// unmarkPreempting clears the preempting flag set by selectAndMarkVictimsLocked,
+// whether the preemption attempt that chose victims ultimately committed or
+// was rolled back. Callers must hold e.mu.
+func (e *schedulerEngine) unmarkPreempting(victims []*internalcache.Namespace) {
+	for _, victim := range victims {
+		e.cache.SetPreempting(victim.GetKey(), false)
+	}
+}
+
+// selectPreemptionVictims builds, for every super cluster that hosts at
+// least one strictly-lower-priority namespace than preemptor, a victim set
+// whose eviction frees at least shortfall slices on that cluster, taking
+// candidates in ascending priority order (larger footprint first among equal
+// priorities) until the shortfall is covered. Among those per-cluster sets it
+// returns the one minimizing in order: number of victims, total victim
+// slices, and the highest victim priority. Other combinations of candidates
+// on the same cluster are not considered.
+func selectPreemptionVictims(preemptor *internalcache.Namespace, shortfall int, all []*internalcache.Namespace) (*preemptionResult, error) {
+	if shortfall <= 0 {
+		return nil, fmt.Errorf("namespace %s has no unplaced slices to preempt for", preemptor.GetKey())
+	}
+
+	candidatesByCluster := make(map[string][]*internalcache.Namespace)
+	for _, ns := range all {
+		if ns.GetKey() == preemptor.GetKey() || ns.GetPriority() >= preemptor.GetPriority() {
+			continue
+		}
+		for cluster := range ns.GetPlacementMap() {
+			candidatesByCluster[cluster] = append(candidatesByCluster[cluster], ns)
+		}
+	}
+
+	var best *preemptionResult
+	var bestScore [3]int
+	for cluster, candidates := range candidatesByCluster {
+		sort.Slice(candidates, func(i, j int) bool {
+			if pi, pj := candidates[i].GetPriority(), candidates[j].GetPriority(); pi != pj {
+				return pi < pj
+			}
+			return candidates[i].GetPlacementMap()[cluster] > candidates[j].GetPlacementMap()[cluster]
+		})
+
+		var victims []*internalcache.Namespace
+		victimFreed := make(map[string]int)
+		freed := 0
+		var highestPriority int32
+		for _, candidate := range candidates {
+			if freed >= shortfall {
+				break
+			}
+			victims = append(victims, candidate)
+			victimFreed[candidate.GetKey()] = candidate.GetPlacementMap()[cluster]
+			freed += candidate.GetPlacementMap()[cluster]
+			if candidate.GetPriority() > highestPriority {
+				highestPriority = candidate.GetPriority()
+			}
+		}
+		if freed < shortfall {
+			continue
+		}
+
+		score := [3]int{len(victims), freed, int(highestPriority)}
+		if best == nil || lessScore(score, bestScore) {
+			best = &preemptionResult{cluster: cluster, victims: victims, freed: freed, victimFreed: victimFreed}
+			bestScore = score
+		}
+	}
+
+	if best == nil {
+		return nil, fmt.Errorf("no preemption candidates would free enough slices for namespace %s", preemptor.GetKey())
+	}
+	return best, nil
+}
+
+func lessScore(a, b [3]int) bool {
+	for i := range a {
+		if a[i] != b[i] {
+			return a[i] < b[i]
+		}
+	}
+	return false
+}
+
+func namespaceKeys(namespaces []*internalcache.Namespace) []string {
+	keys := make([]string, 0, len(namespaces))
+	for _, ns := range namespaces {
+		keys = append(keys, ns.GetKey())
+	}
+	return keys
 }
 
 func (e *schedulerEngine) DeScheduleNamespace(key string) error {
@@ -172,13 +612,16 @@ func (e *schedulerEngine) SchedulePod(pod *internalcache.Pod) (*internalcache.Po
 	if ns == nil {
 		return nil, fmt.Errorf("namespace %s has not been schduled", nsKey)
 	}
+	if e.cache.IsPreempting(nsKey) {
+		return nil, fmt.Errorf("namespace %s placements are being preempted, refusing to schedule pod %s", nsKey, pod.GetKey())
+	}
 
 	snapshot, err := e.cache.SnapshotForPodSched(pod)
 	if err != nil {
 		return nil, err
 	}
 
-	result, err := algorithm.SchedulePod(pod, snapshot)
+	result, err := algorithm.SchedulePod(pod, snapshot, e.framework)
 	if err != nil {
 		return nil, err
 	}
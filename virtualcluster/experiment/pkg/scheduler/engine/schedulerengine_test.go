@@ -0,0 +1,253 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package engine
+
+import (
+	"strings"
+	"testing"
+
+	internalcache "sigs.k8s.io/cluster-api-provider-nested/virtualcluster/experiment/pkg/scheduler/cache"
+)
+
+// TestScheduleNamespaceAutoPreemptionEvictsVictim guards against a regression
+// where auto-preemption inside ScheduleNamespace logged that it was
+// preempting a victim but never actually freed its slices in the cache,
+// leaving the cluster oversubscribed and the victim marked "preempting"
+// forever.
+func TestScheduleNamespaceAutoPreemptionEvictsVictim(t *testing.T) {
+	cache := internalcache.NewSchedulerCache(map[string]int{"c1": 10})
+	e, err := NewSchedulerEngine(cache)
+	if err != nil {
+		t.Fatalf("NewSchedulerEngine: %v", err)
+	}
+
+	victim := internalcache.NewNamespace("victim", 1, 10, 1, internalcache.PlacementPolicyPack)
+	if _, err := e.ScheduleNamespace(victim); err != nil {
+		t.Fatalf("scheduling victim: %v", err)
+	}
+
+	preemptor := internalcache.NewNamespace("preemptor", 1, 5, 5, internalcache.PlacementPolicyPack)
+	ret, err := e.ScheduleNamespace(preemptor)
+	if err != nil {
+		t.Fatalf("expected preemptor to be scheduled after preempting victim, got error: %v", err)
+	}
+	if got := ret.GetPlacementMap()["c1"]; got != 5 {
+		t.Fatalf("expected preemptor to place 5 slices on c1, got %d", got)
+	}
+
+	// selectPreemptionVictims evicts a chosen victim's entire footprint on
+	// the targeted cluster rather than splitting it, so the victim's 10
+	// slices on c1 are fully freed even though only 5 were needed.
+	victimState := cache.GetNamespace("victim")
+	if got := victimState.GetPlacementMap()["c1"]; got != 0 {
+		t.Fatalf("expected victim's placement on c1 to be fully evicted, got %d", got)
+	}
+	if cache.IsPreempting("victim") {
+		t.Fatalf("expected victim to no longer be marked as preempting once eviction committed")
+	}
+}
+
+// TestScheduleNamespaceAutoPreemptionMinimizesShortfall guards against a
+// regression where the shortfall preemption tries to cover was computed from
+// the preemptor's own (often stale or empty) placement map instead of the
+// slices the scheduling pass actually failed to place. With 5 slices already
+// free on the shared cluster, only 3 of the preemptor's 8 slices can fail to
+// place, so a single 5-slice victim should be evicted rather than two.
+func TestScheduleNamespaceAutoPreemptionMinimizesShortfall(t *testing.T) {
+	cache := internalcache.NewSchedulerCache(map[string]int{"c1": 20})
+	e, err := NewSchedulerEngine(cache)
+	if err != nil {
+		t.Fatalf("NewSchedulerEngine: %v", err)
+	}
+
+	for _, key := range []string{"victim-a", "victim-b", "victim-c"} {
+		victim := internalcache.NewNamespace(key, 1, 5, 1, internalcache.PlacementPolicyPack)
+		if _, err := e.ScheduleNamespace(victim); err != nil {
+			t.Fatalf("scheduling %s: %v", key, err)
+		}
+	}
+	// c1 now has 20 - 15 = 5 free slices.
+
+	preemptor := internalcache.NewNamespace("preemptor", 1, 8, 5, internalcache.PlacementPolicyPack)
+	ret, err := e.ScheduleNamespace(preemptor)
+	if err != nil {
+		t.Fatalf("expected preemptor to be scheduled after preempting, got error: %v", err)
+	}
+	if got := ret.GetPlacementMap()["c1"]; got != 8 {
+		t.Fatalf("expected preemptor to place all 8 slices on c1, got %d", got)
+	}
+
+	evicted := 0
+	for _, key := range []string{"victim-a", "victim-b", "victim-c"} {
+		if cache.GetNamespace(key).GetPlacementMap()["c1"] == 0 {
+			evicted++
+		}
+	}
+	if evicted != 1 {
+		t.Fatalf("expected exactly 1 of the 3 equally-sized victims to be evicted to cover a shortfall of 3, got %d", evicted)
+	}
+}
+
+// TestScheduleNamespacePreemptionFailureReturnsPreemptError verifies that
+// when preemption itself cannot find enough victims, ScheduleNamespace
+// surfaces the preemption failure rather than the original (now-misleading)
+// scheduling error.
+func TestScheduleNamespacePreemptionFailureReturnsPreemptError(t *testing.T) {
+	cache := internalcache.NewSchedulerCache(map[string]int{"c1": 5})
+	e, err := NewSchedulerEngine(cache)
+	if err != nil {
+		t.Fatalf("NewSchedulerEngine: %v", err)
+	}
+
+	// Fill the cluster with a namespace at the same priority as the
+	// preemptor, so it is not a valid preemption candidate and there is
+	// nothing to preempt.
+	filler := internalcache.NewNamespace("filler", 1, 5, 5, internalcache.PlacementPolicyPack)
+	if _, err := e.ScheduleNamespace(filler); err != nil {
+		t.Fatalf("scheduling filler: %v", err)
+	}
+
+	preemptor := internalcache.NewNamespace("preemptor", 1, 1, 5, internalcache.PlacementPolicyPack)
+	_, err = e.ScheduleNamespace(preemptor)
+	if err == nil {
+		t.Fatalf("expected scheduling to fail with no preemption candidates available")
+	}
+	if got := err.Error(); !strings.Contains(got, "no preemption candidates") {
+		t.Fatalf("expected the preemption failure reason, got: %v", got)
+	}
+}
+
+// TestPreemptNamespace verifies the standalone PreemptNamespace API selects
+// and marks victims without mutating any placements, leaving the caller
+// responsible for actually descheduling/rescheduling them.
+func TestPreemptNamespace(t *testing.T) {
+	cache := internalcache.NewSchedulerCache(map[string]int{"c1": 10})
+	e, err := NewSchedulerEngine(cache)
+	if err != nil {
+		t.Fatalf("NewSchedulerEngine: %v", err)
+	}
+
+	victim := internalcache.NewNamespace("victim", 1, 10, 1, internalcache.PlacementPolicyPack)
+	if _, err := e.ScheduleNamespace(victim); err != nil {
+		t.Fatalf("scheduling victim: %v", err)
+	}
+	preemptor := internalcache.NewNamespace("preemptor", 1, 5, 5, internalcache.PlacementPolicyPack)
+	if err := cache.AddNamespace(preemptor); err != nil {
+		t.Fatalf("seeding preemptor: %v", err)
+	}
+
+	victims, err := e.PreemptNamespace("preemptor")
+	if err != nil {
+		t.Fatalf("PreemptNamespace: %v", err)
+	}
+	if len(victims) != 1 || victims[0] != "victim" {
+		t.Fatalf("expected [\"victim\"], got %v", victims)
+	}
+
+	// PreemptNamespace only marks victims; it must not touch placements.
+	if got := cache.GetNamespace("victim").GetPlacementMap()["c1"]; got != 10 {
+		t.Fatalf("expected PreemptNamespace not to mutate victim placements, got %d", got)
+	}
+	if !cache.IsPreempting("victim") {
+		t.Fatalf("expected victim to be marked as preempting")
+	}
+}
+
+// TestRebalanceNamespace verifies RebalanceNamespace computes a diff against
+// a namespace's live placements without mutating the cache.
+func TestRebalanceNamespace(t *testing.T) {
+	cache := internalcache.NewSchedulerCache(map[string]int{"c1": 10, "c2": 10})
+	e, err := NewSchedulerEngine(cache)
+	if err != nil {
+		t.Fatalf("NewSchedulerEngine: %v", err)
+	}
+
+	ns := internalcache.NewNamespace("ns", 1, 4, 0, internalcache.PlacementPolicySpread)
+	if _, err := e.ScheduleNamespace(ns); err != nil {
+		t.Fatalf("scheduling ns: %v", err)
+	}
+
+	diff, err := e.RebalanceNamespace("ns")
+	if err != nil {
+		t.Fatalf("RebalanceNamespace: %v", err)
+	}
+	if diff.New["c1"]+diff.New["c2"] != 4 {
+		t.Fatalf("expected the rebalance diff to still place all 4 slices, got %v", diff.New)
+	}
+	// RebalanceNamespace must not mutate the cache: the live placement
+	// should be unchanged and equal to diff.Old.
+	live := cache.GetNamespace("ns").GetPlacementMap()
+	if live["c1"] != diff.Old["c1"] || live["c2"] != diff.Old["c2"] {
+		t.Fatalf("expected RebalanceNamespace not to mutate the cache, live=%v old=%v", live, diff.Old)
+	}
+}
+
+// TestScheduleNamespaceGroupBelowMinMemberAborts verifies that when fewer
+// than MinMember of a group's namespaces can be placed, ScheduleNamespaceGroup
+// returns a GroupScheduleError and rolls back every mutation staged during
+// the attempt, rather than partially committing the group.
+func TestScheduleNamespaceGroupBelowMinMemberAborts(t *testing.T) {
+	cache := internalcache.NewSchedulerCache(map[string]int{"c1": 5})
+	e, err := NewSchedulerEngine(cache)
+	if err != nil {
+		t.Fatalf("NewSchedulerEngine: %v", err)
+	}
+
+	fits := internalcache.NewNamespace("fits", 1, 5, 1, internalcache.PlacementPolicyPack)
+	tooBig := internalcache.NewNamespace("too-big", 1, 10, 1, internalcache.PlacementPolicyPack)
+	group := internalcache.NewNamespaceGroup([]*internalcache.Namespace{fits, tooBig}, 2)
+
+	_, err = e.ScheduleNamespaceGroup(group)
+	if err == nil {
+		t.Fatalf("expected scheduling to fail since only 1 of 2 required members fit")
+	}
+	if _, ok := err.(*GroupScheduleError); !ok {
+		t.Fatalf("expected a *GroupScheduleError, got %T: %v", err, err)
+	}
+	if cache.GetNamespace("fits") != nil {
+		t.Fatalf("expected the placeable member's commit to be rolled back along with the group")
+	}
+}
+
+// TestScheduleNamespaceGroupAtMinMemberCommits verifies that once at least
+// MinMember members can be placed, ScheduleNamespaceGroup commits those
+// placements even though the remaining members failed.
+func TestScheduleNamespaceGroupAtMinMemberCommits(t *testing.T) {
+	cache := internalcache.NewSchedulerCache(map[string]int{"c1": 5})
+	e, err := NewSchedulerEngine(cache)
+	if err != nil {
+		t.Fatalf("NewSchedulerEngine: %v", err)
+	}
+
+	fits := internalcache.NewNamespace("fits", 1, 5, 1, internalcache.PlacementPolicyPack)
+	tooBig := internalcache.NewNamespace("too-big", 1, 10, 1, internalcache.PlacementPolicyPack)
+	group := internalcache.NewNamespaceGroup([]*internalcache.Namespace{fits, tooBig}, 1)
+
+	placed, err := e.ScheduleNamespaceGroup(group)
+	if err != nil {
+		t.Fatalf("expected scheduling to succeed since 1 of 1 required member fits: %v", err)
+	}
+	if _, ok := placed["fits"]; !ok {
+		t.Fatalf("expected \"fits\" to be placed, got %v", placed)
+	}
+	if _, ok := placed["too-big"]; ok {
+		t.Fatalf("expected \"too-big\" to remain unplaced, got %v", placed)
+	}
+	if cache.GetNamespace("fits") == nil {
+		t.Fatalf("expected the committed member's placement to be visible in the cache")
+	}
+}